@@ -1,7 +1,6 @@
 package grpcproxynode
 
 import (
-	"net"
 	"strconv"
 
 	"github.com/zilliztech/milvus-distributed/internal/util/paramtable"
@@ -10,7 +9,7 @@ import (
 type ParamTable struct {
 	paramtable.BaseTable
 
-	ProxyServiceAddress string
+	resolver Resolver
 }
 
 var Params ParamTable
@@ -18,29 +17,55 @@ var Params ParamTable
 func (pt *ParamTable) Init() {
 	pt.BaseTable.Init()
 
-	pt.initProxyServiceAddress()
+	pt.initProxyServiceResolver()
 }
 
-func (pt *ParamTable) initProxyServiceAddress() {
+func (pt *ParamTable) initProxyServiceResolver() {
 	addr, err := pt.Load("proxyService.address")
 	if err != nil {
 		panic(err)
 	}
 
-	hostName, _ := net.LookupHost(addr)
-	if len(hostName) <= 0 {
-		if ip := net.ParseIP(addr); ip == nil {
-			panic("invalid ip proxyService.address")
-		}
-	}
-
 	port, err := pt.Load("proxyService.port")
 	if err != nil {
 		panic(err)
 	}
-	_, err = strconv.Atoi(port)
+	if _, err = strconv.Atoi(port); err != nil {
+		panic(err)
+	}
+
+	resolver, err := newResolver(addr, port)
 	if err != nil {
 		panic(err)
 	}
-	pt.ProxyServiceAddress = addr + ":" + port
+	pt.resolver = resolver
+}
+
+// ProxyServiceAddress returns a single "host:port" dial target, kept for callers
+// that used to read the static ProxyServiceAddress string field. It is the first
+// entry of ProxyServiceAddresses(), which re-resolves and health-checks in the
+// background, so it keeps returning a reachable address after the ProxyService
+// pod IP changes. New code that should react to the full endpoint set should call
+// ProxyServiceAddresses and OnProxyServiceAddressChange instead.
+func (pt *ParamTable) ProxyServiceAddress() string {
+	endpoints := pt.resolver.Endpoints()
+	if len(endpoints) == 0 {
+		return ""
+	}
+	return endpoints[0]
+}
+
+// ProxyServiceAddresses returns the current, health-checked set of ProxyService
+// endpoints. Unlike the static field ProxyServiceAddress used to be, this
+// re-resolves and health-checks in the background, so it keeps returning
+// reachable addresses after the ProxyService pod IP changes.
+func (pt *ParamTable) ProxyServiceAddresses() []string {
+	return pt.resolver.Endpoints()
+}
+
+// OnProxyServiceAddressChange registers fn to run whenever the resolved
+// ProxyService endpoint set changes, so gRPC clients can rebuild their
+// connection balancer instead of polling ProxyServiceAddress.
+func (pt *ParamTable) OnProxyServiceAddressChange(fn ProxyServiceAddressChangeFunc) {
+	pt.resolver.OnChange(fn)
 }
\ No newline at end of file
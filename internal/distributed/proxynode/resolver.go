@@ -0,0 +1,296 @@
+package grpcproxynode
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultResolveTTL bounds how long a Resolver trusts its last resolution before
+// re-resolving, so a ProxyService pod IP change in Kubernetes/DNS-round-robin
+// environments is eventually picked up without a restart.
+const defaultResolveTTL = 30 * time.Second
+
+// defaultHealthCheckTimeout bounds how long Resolver waits for an endpoint to
+// accept a TCP connection before treating it as unreachable.
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// defaultUnhealthyGracePeriod bounds how long a Resolver tolerates zero
+// endpoints answering a health probe - e.g. proxynode starting slightly before
+// proxyService during a rolling deploy - before it stops trusting unprobed
+// candidates and starts returning a hard error instead.
+const defaultUnhealthyGracePeriod = 4 * defaultResolveTTL
+
+// ProxyServiceAddressChangeFunc is invoked with the new endpoint set whenever a
+// Resolver's resolved ProxyService endpoints change.
+type ProxyServiceAddressChangeFunc func(endpoints []string)
+
+// Resolver resolves proxyService.address/proxyService.port into one or more
+// reachable "host:port" endpoints, re-resolving on a TTL and dropping endpoints
+// that fail a health-check probe.
+type Resolver interface {
+	// Endpoints returns the current, health-checked set of endpoints.
+	Endpoints() []string
+	// OnChange registers fn to be called whenever Endpoints() changes.
+	OnChange(fn ProxyServiceAddressChangeFunc)
+	// Close stops background re-resolution.
+	Close()
+}
+
+// ResolverOption configures a dnsResolver built by newResolver.
+type ResolverOption func(*resolverConfig)
+
+type resolverConfig struct {
+	ttl                  time.Duration
+	healthTimeout        time.Duration
+	unhealthyGracePeriod time.Duration
+}
+
+func defaultResolverConfig() resolverConfig {
+	return resolverConfig{
+		ttl:                  defaultResolveTTL,
+		healthTimeout:        defaultHealthCheckTimeout,
+		unhealthyGracePeriod: defaultUnhealthyGracePeriod,
+	}
+}
+
+// WithResolveTTL overrides how often the resolver re-resolves proxyService.address.
+func WithResolveTTL(ttl time.Duration) ResolverOption {
+	return func(c *resolverConfig) { c.ttl = ttl }
+}
+
+// WithHealthCheckTimeout overrides how long the resolver waits for an endpoint's
+// health probe before treating it as unreachable.
+func WithHealthCheckTimeout(timeout time.Duration) ResolverOption {
+	return func(c *resolverConfig) { c.healthTimeout = timeout }
+}
+
+// WithUnhealthyGracePeriod overrides how long the resolver tolerates zero
+// endpoints answering a health probe before it stops trusting unprobed
+// candidates and starts returning a hard error from resolveAndProbe.
+func WithUnhealthyGracePeriod(d time.Duration) ResolverOption {
+	return func(c *resolverConfig) { c.unhealthyGracePeriod = d }
+}
+
+// dnsResolver resolves proxyService.address as one of:
+//   - "srv:<name>": a DNS SRV record lookup, one endpoint per SRV target
+//   - a comma-separated list of host or host:port entries, falling back to
+//     proxyService.port for entries that omit a port
+type dnsResolver struct {
+	raw         string
+	defaultPort string
+	cfg         resolverConfig
+
+	mu        sync.RWMutex
+	endpoints []string
+	listeners []ProxyServiceAddressChangeFunc
+	// unhealthySince is the start of the current run of resolve rounds where not
+	// a single candidate answered a health probe; zero means the last round had
+	// at least one healthy endpoint.
+	unhealthySince time.Time
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// newResolver builds a Resolver for raw/defaultPort and performs its first
+// resolution synchronously, panicking through ParamTable.Init only when
+// proxyService.address yields no candidate endpoint at all (an empty address
+// list or a failed SRV lookup). Plain host entries are not looked up in DNS, so a
+// host that does not resolve or answer is not a startup error; it is tentatively
+// trusted within the unhealthy grace period. It then keeps re-resolving and
+// health-checking endpoints in the background until Close is called.
+func newResolver(raw, defaultPort string, opts ...ResolverOption) (Resolver, error) {
+	cfg := defaultResolverConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &dnsResolver{
+		raw:         raw,
+		defaultPort: defaultPort,
+		cfg:         cfg,
+		stopCh:      make(chan struct{}),
+	}
+	if err := r.resolveAndProbe(); err != nil {
+		return nil, err
+	}
+
+	go r.loop()
+	return r, nil
+}
+
+func (r *dnsResolver) Endpoints() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	endpoints := make([]string, len(r.endpoints))
+	copy(endpoints, r.endpoints)
+	return endpoints
+}
+
+func (r *dnsResolver) OnChange(fn ProxyServiceAddressChangeFunc) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, fn)
+	r.mu.Unlock()
+}
+
+func (r *dnsResolver) Close() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+}
+
+func (r *dnsResolver) loop() {
+	ticker := time.NewTicker(r.cfg.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.resolveAndProbe(); err != nil {
+				log.Println("proxyService resolver: re-resolve failed, keeping last known endpoints:", err)
+			}
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *dnsResolver) resolveAndProbe() error {
+	candidates, err := r.lookup()
+	if err != nil {
+		return err
+	}
+
+	healthy := make([]string, 0, len(candidates))
+	for _, endpoint := range candidates {
+		if probeEndpoint(endpoint, r.cfg.healthTimeout) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+
+	if len(healthy) > 0 {
+		r.mu.Lock()
+		r.unhealthySince = time.Time{}
+		changed := !sameEndpoints(r.endpoints, healthy)
+		r.endpoints = healthy
+		listeners := append([]ProxyServiceAddressChangeFunc(nil), r.listeners...)
+		r.mu.Unlock()
+
+		if changed {
+			for _, notify := range listeners {
+				go notify(healthy)
+			}
+		}
+		return nil
+	}
+
+	// Every candidate failed its health probe this round. Tolerate that for up
+	// to unhealthyGracePeriod - e.g. proxynode starting slightly before
+	// proxyService's listener is up during a rolling deploy - rather than
+	// treating it as a permanent misconfiguration immediately.
+	r.mu.Lock()
+	if r.unhealthySince.IsZero() {
+		r.unhealthySince = time.Now()
+	}
+	unhealthyFor := time.Since(r.unhealthySince)
+	withinGrace := unhealthyFor < r.cfg.unhealthyGracePeriod
+	hasKnownGood := len(r.endpoints) > 0
+
+	var changed bool
+	var listeners []ProxyServiceAddressChangeFunc
+	if withinGrace && !hasKnownGood {
+		// No known-good set yet: tentatively trust DNS-resolved candidates so
+		// startup stays as permissive as the old DNS-only check, but only while
+		// within the grace period.
+		changed = !sameEndpoints(r.endpoints, candidates)
+		r.endpoints = candidates
+		listeners = append([]ProxyServiceAddressChangeFunc(nil), r.listeners...)
+	}
+	r.mu.Unlock()
+
+	switch {
+	case withinGrace && hasKnownGood:
+		log.Println("proxyService resolver: no endpoint healthy this round, keeping last known-good endpoints:", unhealthyFor)
+		return nil
+	case withinGrace:
+		log.Println("proxyService resolver: no endpoint healthy yet within grace period, tentatively trusting DNS:", candidates)
+		if changed {
+			for _, notify := range listeners {
+				go notify(candidates)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("proxyService resolver: no reachable endpoints for %q after %s (unhealthy grace period exceeded)", r.raw, unhealthyFor)
+	}
+}
+
+func (r *dnsResolver) lookup() ([]string, error) {
+	if name := strings.TrimPrefix(r.raw, "srv:"); name != r.raw {
+		return lookupSRV(name)
+	}
+
+	parts := strings.Split(r.raw, ",")
+	endpoints := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		endpoints = append(endpoints, withDefaultPort(part, r.defaultPort))
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("proxyService resolver: empty proxyService.address")
+	}
+	return endpoints, nil
+}
+
+func lookupSRV(name string) ([]string, error) {
+	_, srvs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]string, 0, len(srvs))
+	for _, srv := range srvs {
+		target := strings.TrimSuffix(srv.Target, ".")
+		endpoints = append(endpoints, net.JoinHostPort(target, strconv.Itoa(int(srv.Port))))
+	}
+	return endpoints, nil
+}
+
+func withDefaultPort(hostport, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(hostport); err == nil {
+		return hostport
+	}
+	return net.JoinHostPort(hostport, defaultPort)
+}
+
+func probeEndpoint(endpoint string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", endpoint, timeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+func sameEndpoints(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
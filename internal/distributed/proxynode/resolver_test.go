@@ -0,0 +1,146 @@
+package grpcproxynode
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestWithDefaultPort(t *testing.T) {
+	cases := []struct {
+		name        string
+		hostport    string
+		defaultPort string
+		want        string
+	}{
+		{"host without port gets the default port", "example.com", "19530", "example.com:19530"},
+		{"host with its own port is left alone", "example.com:8080", "19530", "example.com:8080"},
+		{"bare IPv4 without port gets the default port", "10.0.0.1", "19530", "10.0.0.1:19530"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := withDefaultPort(tc.hostport, tc.defaultPort); got != tc.want {
+				t.Errorf("withDefaultPort(%q, %q) = %q, want %q", tc.hostport, tc.defaultPort, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSameEndpoints(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []string
+		want bool
+	}{
+		{"equal sets in the same order", []string{"a:1", "b:2"}, []string{"a:1", "b:2"}, true},
+		{"equal sets in different order", []string{"a:1", "b:2"}, []string{"b:2", "a:1"}, true},
+		{"different lengths", []string{"a:1"}, []string{"a:1", "b:2"}, false},
+		{"different contents", []string{"a:1", "b:2"}, []string{"a:1", "c:3"}, false},
+		{"both empty", nil, []string{}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := sameEndpoints(tc.a, tc.b); got != tc.want {
+				t.Errorf("sameEndpoints(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDnsResolverLookupCommaSeparatedList(t *testing.T) {
+	r := &dnsResolver{raw: "host-a, host-b:8080, host-c", defaultPort: "19530"}
+
+	got, err := r.lookup()
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+
+	want := []string{"host-a:19530", "host-b:8080", "host-c:19530"}
+	if len(got) != len(want) {
+		t.Fatalf("lookup() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("lookup()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDnsResolverLookupEmptyAddress(t *testing.T) {
+	r := &dnsResolver{raw: "  , ,  ", defaultPort: "19530"}
+
+	if _, err := r.lookup(); err == nil {
+		t.Error("lookup() error = nil, want an error for an address with no usable entries")
+	}
+}
+
+func TestProbeEndpoint(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start test listener: %v", err)
+	}
+	defer listener.Close()
+
+	if !probeEndpoint(listener.Addr().String(), time.Second) {
+		t.Error("probeEndpoint() = false for a reachable listener, want true")
+	}
+
+	if probeEndpoint("127.0.0.1:1", 100*time.Millisecond) {
+		t.Error("probeEndpoint() = true for a closed port, want false")
+	}
+}
+
+func TestResolveAndProbeKeepsLastKnownGoodWithinGrace(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start test listener: %v", err)
+	}
+	defer listener.Close()
+
+	r := &dnsResolver{
+		raw:         listener.Addr().String(),
+		defaultPort: "0",
+		cfg: resolverConfig{
+			healthTimeout:        100 * time.Millisecond,
+			unhealthyGracePeriod: time.Hour,
+		},
+	}
+
+	if err := r.resolveAndProbe(); err != nil {
+		t.Fatalf("first resolveAndProbe() error = %v, want nil with a reachable listener", err)
+	}
+
+	listener.Close()
+
+	if err := r.resolveAndProbe(); err != nil {
+		t.Fatalf("resolveAndProbe() error = %v, want nil while within the unhealthy grace period", err)
+	}
+	if got := r.Endpoints(); len(got) != 1 || got[0] != listener.Addr().String() {
+		t.Errorf("Endpoints() = %v, want the last known-good endpoint %q kept", got, listener.Addr().String())
+	}
+}
+
+func TestResolveAndProbeErrorsAfterGracePeriodExceeded(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start test listener: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	r := &dnsResolver{
+		raw:         addr,
+		defaultPort: "0",
+		cfg: resolverConfig{
+			healthTimeout:        50 * time.Millisecond,
+			unhealthyGracePeriod: 10 * time.Millisecond,
+		},
+		unhealthySince: time.Now().Add(-time.Hour),
+	}
+
+	if err := r.resolveAndProbe(); err == nil {
+		t.Error("resolveAndProbe() error = nil, want a hard error once the grace period has been exceeded")
+	}
+}
@@ -0,0 +1,375 @@
+package msgstream
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zilliztech/milvus-distributed/internal/proto/commonpb"
+	"github.com/zilliztech/milvus-distributed/internal/proto/internalpb"
+)
+
+// DefaultMaxBytesPerMsg bounds the serialized row payload carried by a single
+// batched message when the caller does not override it via WithMaxBytesPerMsg.
+const DefaultMaxBytesPerMsg = 4 << 20 // 4MB
+
+// RepackObserver is notified once per output bucket every time a batched repack
+// func finishes a call, so callers can tune batch size at runtime.
+type RepackObserver interface {
+	// Observe reports, for one output bucket, how many rows went in, how many messages
+	// came out, and the resulting average batch size.
+	Observe(key int32, rowsIn int, msgsOut int, avgBatchSize float64)
+}
+
+// RepackOption configures the batching behavior of BatchedInsertRepackFunc and
+// BatchedDeleteRepackFunc.
+type RepackOption func(*repackOptions)
+
+type repackOptions struct {
+	maxBytesPerMsg int
+	watermark      uint64
+	observer       RepackObserver
+	partitioner    Partitioner
+}
+
+func defaultRepackOptions() repackOptions {
+	return repackOptions{
+		maxBytesPerMsg: DefaultMaxBytesPerMsg,
+		partitioner:    defaultPartitioner,
+	}
+}
+
+// WithPartitioner routes bucket assignment through p instead of trusting the
+// caller-supplied hash keys verbatim, the same partitioning core RepackFuncBuilder
+// composes InsertRepackFunc/DeleteRepackFunc/DefaultRepackFunc from.
+func WithPartitioner(p Partitioner) RepackOption {
+	return func(o *repackOptions) {
+		o.partitioner = p
+	}
+}
+
+// WithMaxBytesPerMsg caps the serialized row payload carried by a single batched
+// message, flushing the in-progress batch before the cap would be exceeded.
+func WithMaxBytesPerMsg(maxBytes int) RepackOption {
+	return func(o *repackOptions) {
+		o.maxBytesPerMsg = maxBytes
+	}
+}
+
+// WithWatermark flushes the in-progress batch as soon as a row's timestamp crosses
+// watermark ticks past the batch's first timestamp, bounding how stale a partial
+// batch is allowed to get.
+func WithWatermark(watermark uint64) RepackOption {
+	return func(o *repackOptions) {
+		o.watermark = watermark
+	}
+}
+
+// WithRepackObserver registers an observer that receives per-key batching metrics
+// (rows in, messages out, average batch size) after each call to the RepackFunc.
+func WithRepackObserver(observer RepackObserver) RepackOption {
+	return func(o *repackOptions) {
+		o.observer = observer
+	}
+}
+
+type batchStats struct {
+	rowsIn  int
+	msgsOut int
+}
+
+func (s *batchStats) report(key int32, observer RepackObserver) {
+	if observer == nil {
+		return
+	}
+	avg := 0.0
+	if s.msgsOut > 0 {
+		avg = float64(s.rowsIn) / float64(s.msgsOut)
+	}
+	observer.Observe(key, s.rowsIn, s.msgsOut, avg)
+}
+
+type insertBatch struct {
+	ctx            context.Context
+	base           *commonpb.MsgBase
+	dbID           int64
+	collectionID   int64
+	partitionID    int64
+	collectionName string
+	partitionName  string
+	segmentID      int64
+	channelID      int64
+	firstTs        uint64
+	bytes          int
+	timestamps     []uint64
+	rowIDs         []int64
+	rowData        []*commonpb.Blob
+}
+
+func (b *insertBatch) sameGroup(req *InsertMsg) bool {
+	return b.dbID == req.DbID &&
+		b.collectionID == req.CollectionID &&
+		b.partitionID == req.PartitionID &&
+		b.segmentID == req.SegmentID &&
+		b.channelID == req.ChannelID
+}
+
+func (b *insertBatch) toMsg() *InsertMsg {
+	return &InsertMsg{
+		BaseMsg: BaseMsg{Ctx: b.ctx},
+		InsertRequest: internalpb.InsertRequest{
+			Base:           b.base,
+			DbID:           b.dbID,
+			CollectionID:   b.collectionID,
+			PartitionID:    b.partitionID,
+			CollectionName: b.collectionName,
+			PartitionName:  b.partitionName,
+			SegmentID:      b.segmentID,
+			ChannelID:      b.channelID,
+			Timestamps:     b.timestamps,
+			RowIDs:         b.rowIDs,
+			RowData:        b.rowData,
+		},
+	}
+}
+
+// BatchedInsertRepackFunc returns a RepackFunc that behaves like InsertRepackFunc
+// but groups up to batchSize consecutive rows destined for the same bucket into a
+// single InsertMsg (carrying slices of Timestamps/RowIDs/RowData) instead of
+// emitting one InsertMsg per row, preserving row order within each bucket. Buckets
+// are assigned by the same Partitioner core RepackFuncBuilder uses (ModuloPartitioner,
+// i.e. the caller-supplied hash key, unless overridden via WithPartitioner). A batch
+// is also flushed early once it would exceed maxBytesPerMsg or cross the configured
+// watermark; see WithMaxBytesPerMsg, WithWatermark and WithRepackObserver.
+func BatchedInsertRepackFunc(batchSize int, opts ...RepackOption) RepackFunc {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	options := defaultRepackOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, error) {
+		result := make(map[int32]*MsgPack)
+		batches := make(map[int32]*insertBatch)
+		stats := make(map[int32]*batchStats)
+
+		flush := func(key int32) {
+			b := batches[key]
+			if b == nil {
+				return
+			}
+			if _, ok := result[key]; !ok {
+				result[key] = &MsgPack{}
+			}
+			result[key].Msgs = append(result[key].Msgs, b.toMsg())
+			stats[key].msgsOut++
+			batches[key] = nil
+		}
+
+		for i, request := range tsMsgs {
+			if request.Type() != commonpb.MsgType_Insert {
+				return nil, errors.New("msg's must be Insert")
+			}
+			insertRequest := request.(*InsertMsg)
+			keys := hashKeys[i]
+
+			if len(keys) != len(insertRequest.Timestamps) || len(keys) != len(insertRequest.RowIDs) || len(keys) != len(insertRequest.RowData) {
+				return nil, errors.New("the length of hashValue, timestamps, rowIDs, RowData are not equal")
+			}
+
+			for index, rawKey := range keys {
+				bucket := options.partitioner.Partition(PartitionKey{
+					HashKey:     rawKey,
+					SegmentID:   insertRequest.SegmentID,
+					PartitionID: insertRequest.PartitionID,
+				})
+
+				if stats[bucket] == nil {
+					stats[bucket] = &batchStats{}
+				}
+				stats[bucket].rowsIn++
+
+				ts := insertRequest.Timestamps[index]
+				row := insertRequest.RowData[index]
+				rowBytes := 0
+				if row != nil {
+					rowBytes = len(row.Value)
+				}
+
+				b := batches[bucket]
+				if b != nil && (!b.sameGroup(insertRequest) ||
+					len(b.timestamps) >= batchSize ||
+					(options.maxBytesPerMsg > 0 && b.bytes+rowBytes > options.maxBytesPerMsg) ||
+					(options.watermark > 0 && ts > b.firstTs && ts-b.firstTs >= options.watermark)) {
+					flush(bucket)
+					b = nil
+				}
+
+				if b == nil {
+					b = &insertBatch{
+						ctx: request.TraceCtx(),
+						base: &commonpb.MsgBase{
+							MsgType:   commonpb.MsgType_Insert,
+							MsgID:     insertRequest.Base.MsgID,
+							Timestamp: ts,
+							SourceID:  insertRequest.Base.SourceID,
+						},
+						dbID:           insertRequest.DbID,
+						collectionID:   insertRequest.CollectionID,
+						partitionID:    insertRequest.PartitionID,
+						collectionName: insertRequest.CollectionName,
+						partitionName:  insertRequest.PartitionName,
+						segmentID:      insertRequest.SegmentID,
+						channelID:      insertRequest.ChannelID,
+						firstTs:        ts,
+					}
+					batches[bucket] = b
+				}
+
+				b.timestamps = append(b.timestamps, ts)
+				b.rowIDs = append(b.rowIDs, insertRequest.RowIDs[index])
+				b.rowData = append(b.rowData, row)
+				b.bytes += rowBytes
+			}
+		}
+
+		for key := range batches {
+			flush(key)
+		}
+
+		for key, s := range stats {
+			s.report(key, options.observer)
+		}
+
+		return result, nil
+	}
+}
+
+type deleteBatch struct {
+	ctx            context.Context
+	base           *commonpb.MsgBase
+	collectionName string
+	channelID      int64
+	firstTs        uint64
+	bytes          int
+	timestamps     []uint64
+	primaryKeys    []int64
+}
+
+func (b *deleteBatch) sameGroup(req *DeleteMsg) bool {
+	return b.collectionName == req.CollectionName && b.channelID == req.ChannelID
+}
+
+func (b *deleteBatch) toMsg() *DeleteMsg {
+	return &DeleteMsg{
+		BaseMsg: BaseMsg{Ctx: b.ctx},
+		DeleteRequest: internalpb.DeleteRequest{
+			Base:           b.base,
+			CollectionName: b.collectionName,
+			ChannelID:      b.channelID,
+			Timestamps:     b.timestamps,
+			PrimaryKeys:    b.primaryKeys,
+		},
+	}
+}
+
+// BatchedDeleteRepackFunc is the DeleteMsg counterpart of BatchedInsertRepackFunc:
+// it groups up to batchSize consecutive rows destined for the same bucket into a
+// single DeleteMsg, preserving row order within each bucket. See
+// BatchedInsertRepackFunc for the flushing rules and partitioning core shared with
+// the insert path.
+func BatchedDeleteRepackFunc(batchSize int, opts ...RepackOption) RepackFunc {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	options := defaultRepackOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, error) {
+		result := make(map[int32]*MsgPack)
+		batches := make(map[int32]*deleteBatch)
+		stats := make(map[int32]*batchStats)
+
+		flush := func(key int32) {
+			b := batches[key]
+			if b == nil {
+				return
+			}
+			if _, ok := result[key]; !ok {
+				result[key] = &MsgPack{}
+			}
+			result[key].Msgs = append(result[key].Msgs, b.toMsg())
+			stats[key].msgsOut++
+			batches[key] = nil
+		}
+
+		for i, request := range tsMsgs {
+			if request.Type() != commonpb.MsgType_Delete {
+				return nil, errors.New("msg's must be Delete")
+			}
+			deleteRequest := request.(*DeleteMsg)
+			keys := hashKeys[i]
+
+			if len(keys) != len(deleteRequest.Timestamps) || len(keys) != len(deleteRequest.PrimaryKeys) {
+				return nil, errors.New("the length of hashValue, timestamps, primaryKeys are not equal")
+			}
+
+			for index, rawKey := range keys {
+				bucket := options.partitioner.Partition(PartitionKey{
+					HashKey:    rawKey,
+					PrimaryKey: deleteRequest.PrimaryKeys[index],
+				})
+
+				if stats[bucket] == nil {
+					stats[bucket] = &batchStats{}
+				}
+				stats[bucket].rowsIn++
+
+				ts := deleteRequest.Timestamps[index]
+				rowBytes := 8 // primary key
+
+				b := batches[bucket]
+				if b != nil && (!b.sameGroup(deleteRequest) ||
+					len(b.timestamps) >= batchSize ||
+					(options.maxBytesPerMsg > 0 && b.bytes+rowBytes > options.maxBytesPerMsg) ||
+					(options.watermark > 0 && ts > b.firstTs && ts-b.firstTs >= options.watermark)) {
+					flush(bucket)
+					b = nil
+				}
+
+				if b == nil {
+					b = &deleteBatch{
+						ctx: request.TraceCtx(),
+						base: &commonpb.MsgBase{
+							MsgType:   commonpb.MsgType_Delete,
+							MsgID:     deleteRequest.Base.MsgID,
+							Timestamp: ts,
+							SourceID:  deleteRequest.Base.SourceID,
+						},
+						collectionName: deleteRequest.CollectionName,
+						channelID:      deleteRequest.ChannelID,
+						firstTs:        ts,
+					}
+					batches[bucket] = b
+				}
+
+				b.timestamps = append(b.timestamps, ts)
+				b.primaryKeys = append(b.primaryKeys, deleteRequest.PrimaryKeys[index])
+				b.bytes += rowBytes
+			}
+		}
+
+		for key := range batches {
+			flush(key)
+		}
+
+		for key, s := range stats {
+			s.report(key, options.observer)
+		}
+
+		return result, nil
+	}
+}
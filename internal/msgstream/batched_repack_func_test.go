@@ -0,0 +1,132 @@
+package msgstream
+
+import (
+	"testing"
+
+	"github.com/zilliztech/milvus-distributed/internal/proto/commonpb"
+	"github.com/zilliztech/milvus-distributed/internal/proto/internalpb"
+)
+
+func newTestInsertMsg(segmentID int64, rowIDs []int64, timestamps []uint64) *InsertMsg {
+	rowData := make([]*commonpb.Blob, len(rowIDs))
+	for i := range rowData {
+		rowData[i] = &commonpb.Blob{Value: []byte{byte(i)}}
+	}
+	return &InsertMsg{
+		BaseMsg: BaseMsg{},
+		InsertRequest: internalpb.InsertRequest{
+			Base:       &commonpb.MsgBase{MsgType: commonpb.MsgType_Insert},
+			SegmentID:  segmentID,
+			Timestamps: timestamps,
+			RowIDs:     rowIDs,
+			RowData:    rowData,
+		},
+	}
+}
+
+func zeroHashKeys(n int) []int32 {
+	keys := make([]int32, n)
+	for i := range keys {
+		keys[i] = 0
+	}
+	return keys
+}
+
+func TestBatchedInsertRepackFuncFlushesOnBatchSize(t *testing.T) {
+	msg := newTestInsertMsg(1, []int64{1, 2, 3}, []uint64{10, 11, 12})
+	repack := BatchedInsertRepackFunc(2)
+
+	result, err := repack([]TsMsg{msg}, [][]int32{zeroHashKeys(3)})
+	if err != nil {
+		t.Fatalf("repack() error = %v", err)
+	}
+
+	msgs := result[0].Msgs
+	if len(msgs) != 2 {
+		t.Fatalf("got %d batched messages, want 2 (one full batch of 2 rows, one of 1)", len(msgs))
+	}
+	first := msgs[0].(*InsertMsg)
+	if len(first.RowIDs) != 2 {
+		t.Errorf("first batch has %d rows, want 2", len(first.RowIDs))
+	}
+	second := msgs[1].(*InsertMsg)
+	if len(second.RowIDs) != 1 {
+		t.Errorf("second batch has %d rows, want 1", len(second.RowIDs))
+	}
+}
+
+func TestBatchedInsertRepackFuncFlushesOnMaxBytes(t *testing.T) {
+	msg := newTestInsertMsg(1, []int64{1, 2, 3}, []uint64{10, 11, 12})
+	repack := BatchedInsertRepackFunc(100, WithMaxBytesPerMsg(1))
+
+	result, err := repack([]TsMsg{msg}, [][]int32{zeroHashKeys(3)})
+	if err != nil {
+		t.Fatalf("repack() error = %v", err)
+	}
+
+	if got := len(result[0].Msgs); got != 3 {
+		t.Fatalf("got %d batched messages, want 3 (maxBytesPerMsg=1 forces a flush per row)", got)
+	}
+}
+
+func TestBatchedInsertRepackFuncFlushesOnWatermark(t *testing.T) {
+	msg := newTestInsertMsg(1, []int64{1, 2, 3}, []uint64{10, 15, 25})
+	repack := BatchedInsertRepackFunc(100, WithWatermark(10))
+
+	result, err := repack([]TsMsg{msg}, [][]int32{zeroHashKeys(3)})
+	if err != nil {
+		t.Fatalf("repack() error = %v", err)
+	}
+
+	msgs := result[0].Msgs
+	if len(msgs) != 2 {
+		t.Fatalf("got %d batched messages, want 2 (ts 25 crosses the watermark of 10 past ts 10)", len(msgs))
+	}
+}
+
+func TestBatchedInsertRepackFuncReportsObserver(t *testing.T) {
+	msg := newTestInsertMsg(1, []int64{1, 2, 3}, []uint64{10, 11, 12})
+
+	var gotKey int32
+	var gotRowsIn, gotMsgsOut int
+	var gotAvg float64
+	observer := repackObserverFunc(func(key int32, rowsIn int, msgsOut int, avgBatchSize float64) {
+		gotKey, gotRowsIn, gotMsgsOut, gotAvg = key, rowsIn, msgsOut, avgBatchSize
+	})
+
+	repack := BatchedInsertRepackFunc(2, WithRepackObserver(observer))
+	if _, err := repack([]TsMsg{msg}, [][]int32{zeroHashKeys(3)}); err != nil {
+		t.Fatalf("repack() error = %v", err)
+	}
+
+	if gotKey != 0 || gotRowsIn != 3 || gotMsgsOut != 2 {
+		t.Errorf("observer saw key=%d rowsIn=%d msgsOut=%d, want key=0 rowsIn=3 msgsOut=2", gotKey, gotRowsIn, gotMsgsOut)
+	}
+	if want := 1.5; gotAvg != want {
+		t.Errorf("observer saw avgBatchSize=%v, want %v", gotAvg, want)
+	}
+}
+
+func TestBatchedInsertRepackFuncUsesPartitioner(t *testing.T) {
+	msg := newTestInsertMsg(42, []int64{1, 2}, []uint64{10, 11})
+	repack := BatchedInsertRepackFunc(100, WithPartitioner(&AffinityPartitioner{}))
+
+	result, err := repack([]TsMsg{msg}, [][]int32{{0, 0}})
+	if err != nil {
+		t.Fatalf("repack() error = %v", err)
+	}
+
+	want := int32(hashToUint32("segment#42"))
+	if _, ok := result[want]; !ok {
+		t.Fatalf("result has no bucket %d, want rows routed by segment affinity; buckets = %v", want, result)
+	}
+	if len(result[want].Msgs) != 1 || len(result[want].Msgs[0].(*InsertMsg).RowIDs) != 2 {
+		t.Errorf("expected both rows batched together in bucket %d", want)
+	}
+}
+
+type repackObserverFunc func(key int32, rowsIn int, msgsOut int, avgBatchSize float64)
+
+func (f repackObserverFunc) Observe(key int32, rowsIn int, msgsOut int, avgBatchSize float64) {
+	f(key, rowsIn, msgsOut, avgBatchSize)
+}
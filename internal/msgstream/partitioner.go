@@ -0,0 +1,164 @@
+package msgstream
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// PartitionKey carries everything a Partitioner needs to place a single row,
+// without requiring it to understand the concrete TsMsg type the row came from.
+type PartitionKey struct {
+	// HashKey is the hash value the caller originally computed for the row.
+	HashKey int32
+	// PrimaryKey is the row's primary key, used by RangePartitioner.
+	PrimaryKey int64
+	// SegmentID and PartitionID are used by AffinityPartitioner to keep rows that
+	// belong together in the same output bucket. Zero means "unknown".
+	SegmentID   int64
+	PartitionID int64
+}
+
+// Partitioner decides which bucket a row belongs to. RepackFuncBuilder composes a
+// RepackFunc around a Partitioner so InsertRepackFunc, DeleteRepackFunc and
+// DefaultRepackFunc all share the same partitioning core.
+type Partitioner interface {
+	// Name identifies the partitioning strategy, used for logging/observability.
+	Name() string
+	// Partition returns the bucket key a row should be repacked into.
+	Partition(key PartitionKey) int32
+}
+
+// RebalanceObserver reports how disruptive a Partitioner is relative to the
+// caller-supplied hash keys: of the rows repacked in one call, the fraction that
+// landed in a different bucket than their original hash key.
+type RebalanceObserver func(movedKeys, totalKeys int, ratio float64)
+
+// ModuloPartitioner reproduces the repack functions' original behavior: it treats
+// the caller-supplied hash key as the bucket directly. Once NumBuckets is set to a
+// positive shard count it instead folds the hash key into NumBuckets buckets via
+// modulo, which is the conventional "modulo hashing" strategy.
+type ModuloPartitioner struct {
+	NumBuckets int
+}
+
+// Name implements Partitioner.
+func (p *ModuloPartitioner) Name() string { return "modulo" }
+
+// Partition implements Partitioner.
+func (p *ModuloPartitioner) Partition(key PartitionKey) int32 {
+	if p.NumBuckets <= 0 {
+		return key.HashKey
+	}
+	bucket := key.HashKey % int32(p.NumBuckets)
+	if bucket < 0 {
+		bucket += int32(p.NumBuckets)
+	}
+	return bucket
+}
+
+// defaultPartitioner preserves the exact behavior InsertRepackFunc, DeleteRepackFunc
+// and DefaultRepackFunc had before Partitioner was introduced.
+var defaultPartitioner Partitioner = &ModuloPartitioner{}
+
+type consistentHashNode struct {
+	hash   uint32
+	bucket int32
+}
+
+// ConsistentHashPartitioner distributes rows across Buckets using consistent
+// hashing with VirtualNodes replicas per bucket on the ring, so adding or removing
+// a bucket only reshuffles the rows that land near the changed part of the ring
+// instead of reshuffling everything the way ModuloPartitioner does.
+type ConsistentHashPartitioner struct {
+	Buckets      []int32
+	VirtualNodes int
+
+	once sync.Once
+	ring []consistentHashNode
+}
+
+// Name implements Partitioner.
+func (p *ConsistentHashPartitioner) Name() string { return "consistent-hash" }
+
+func (p *ConsistentHashPartitioner) buildRing() {
+	virtualNodes := p.VirtualNodes
+	if virtualNodes <= 0 {
+		virtualNodes = 100
+	}
+	ring := make([]consistentHashNode, 0, len(p.Buckets)*virtualNodes)
+	for _, bucket := range p.Buckets {
+		for v := 0; v < virtualNodes; v++ {
+			ring = append(ring, consistentHashNode{
+				hash:   hashToUint32(strconv.Itoa(int(bucket)) + "#" + strconv.Itoa(v)),
+				bucket: bucket,
+			})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	p.ring = ring
+}
+
+// Partition implements Partitioner.
+func (p *ConsistentHashPartitioner) Partition(key PartitionKey) int32 {
+	p.once.Do(p.buildRing)
+	if len(p.ring) == 0 {
+		return key.HashKey
+	}
+	h := hashToUint32(strconv.Itoa(int(key.HashKey)))
+	idx := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	if idx == len(p.ring) {
+		idx = 0
+	}
+	return p.ring[idx].bucket
+}
+
+func hashToUint32(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// RangePartitioner assigns a row to the first bucket whose UpperBounds entry
+// exceeds the row's primary key, so that contiguous ranges of primary keys land in
+// the same bucket. Rows at or above the last bound fall into the final bucket.
+type RangePartitioner struct {
+	UpperBounds []int64
+}
+
+// Name implements Partitioner.
+func (p *RangePartitioner) Name() string { return "range" }
+
+// Partition implements Partitioner.
+func (p *RangePartitioner) Partition(key PartitionKey) int32 {
+	idx := sort.Search(len(p.UpperBounds), func(i int) bool {
+		return key.PrimaryKey < p.UpperBounds[i]
+	})
+	return int32(idx)
+}
+
+// AffinityPartitioner keeps every row belonging to the same SegmentID (or, absent a
+// SegmentID, the same PartitionID) in a single bucket, falling back to Fallback for
+// rows that carry neither. IDs are hashed rather than cast to int32, since two
+// unrelated 64-bit IDs can otherwise collide in their low 32 bits.
+type AffinityPartitioner struct {
+	Fallback Partitioner
+}
+
+// Name implements Partitioner.
+func (p *AffinityPartitioner) Name() string { return "affinity" }
+
+// Partition implements Partitioner.
+func (p *AffinityPartitioner) Partition(key PartitionKey) int32 {
+	switch {
+	case key.SegmentID != 0:
+		return int32(hashToUint32("segment#" + strconv.FormatInt(key.SegmentID, 10)))
+	case key.PartitionID != 0:
+		return int32(hashToUint32("partition#" + strconv.FormatInt(key.PartitionID, 10)))
+	case p.Fallback != nil:
+		return p.Fallback.Partition(key)
+	default:
+		return key.HashKey
+	}
+}
@@ -0,0 +1,120 @@
+package msgstream
+
+import "testing"
+
+func TestModuloPartitioner(t *testing.T) {
+	cases := []struct {
+		name       string
+		numBuckets int
+		hashKey    int32
+		want       int32
+	}{
+		{"no buckets configured returns hash key verbatim", 0, 7, 7},
+		{"positive hash key folds into bucket count", 4, 7, 3},
+		{"negative hash key still folds into a non-negative bucket", 4, -1, 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &ModuloPartitioner{NumBuckets: tc.numBuckets}
+			if got := p.Partition(PartitionKey{HashKey: tc.hashKey}); got != tc.want {
+				t.Errorf("Partition() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConsistentHashPartitioner(t *testing.T) {
+	p := &ConsistentHashPartitioner{Buckets: []int32{0, 1, 2}, VirtualNodes: 50}
+
+	first := p.Partition(PartitionKey{HashKey: 42})
+	for i := 0; i < 10; i++ {
+		if got := p.Partition(PartitionKey{HashKey: 42}); got != first {
+			t.Fatalf("Partition() not stable across repeated calls: got %d, want %d", got, first)
+		}
+	}
+
+	found := false
+	for _, bucket := range p.Buckets {
+		if bucket == first {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Errorf("Partition() = %d, not one of the configured buckets %v", first, p.Buckets)
+	}
+}
+
+func TestConsistentHashPartitionerEmptyRing(t *testing.T) {
+	p := &ConsistentHashPartitioner{}
+	if got := p.Partition(PartitionKey{HashKey: 9}); got != 9 {
+		t.Errorf("Partition() with no buckets = %d, want hash key 9 verbatim", got)
+	}
+}
+
+func TestRangePartitioner(t *testing.T) {
+	p := &RangePartitioner{UpperBounds: []int64{10, 20, 30}}
+
+	cases := []struct {
+		primaryKey int64
+		want       int32
+	}{
+		{primaryKey: 5, want: 0},
+		{primaryKey: 9, want: 0},
+		{primaryKey: 10, want: 1},
+		{primaryKey: 15, want: 1},
+		{primaryKey: 29, want: 2},
+		{primaryKey: 30, want: 3},
+		{primaryKey: 1000, want: 3},
+	}
+
+	for _, tc := range cases {
+		if got := p.Partition(PartitionKey{PrimaryKey: tc.primaryKey}); got != tc.want {
+			t.Errorf("Partition(%d) = %d, want %d", tc.primaryKey, got, tc.want)
+		}
+	}
+}
+
+func TestAffinityPartitioner(t *testing.T) {
+	t.Run("rows with the same segment ID land in the same bucket", func(t *testing.T) {
+		p := &AffinityPartitioner{}
+		a := p.Partition(PartitionKey{SegmentID: 100, HashKey: 1})
+		b := p.Partition(PartitionKey{SegmentID: 100, HashKey: 2})
+		if a != b {
+			t.Errorf("same SegmentID produced different buckets: %d vs %d", a, b)
+		}
+	})
+
+	t.Run("different segment IDs usually land in different buckets", func(t *testing.T) {
+		p := &AffinityPartitioner{}
+		a := p.Partition(PartitionKey{SegmentID: 100})
+		b := p.Partition(PartitionKey{SegmentID: 200})
+		if a == b {
+			t.Errorf("distinct SegmentIDs collided into the same bucket: %d", a)
+		}
+	})
+
+	t.Run("falls back to partition ID when segment ID is absent", func(t *testing.T) {
+		p := &AffinityPartitioner{}
+		a := p.Partition(PartitionKey{PartitionID: 100, HashKey: 1})
+		b := p.Partition(PartitionKey{PartitionID: 100, HashKey: 2})
+		if a != b {
+			t.Errorf("same PartitionID produced different buckets: %d vs %d", a, b)
+		}
+	})
+
+	t.Run("falls back to Fallback partitioner when neither ID is set", func(t *testing.T) {
+		p := &AffinityPartitioner{Fallback: &ModuloPartitioner{NumBuckets: 4}}
+		if got := p.Partition(PartitionKey{HashKey: 7}); got != 3 {
+			t.Errorf("Partition() = %d, want Fallback's result 3", got)
+		}
+	})
+
+	t.Run("falls back to hash key verbatim with neither ID nor Fallback", func(t *testing.T) {
+		p := &AffinityPartitioner{}
+		if got := p.Partition(PartitionKey{HashKey: 9}); got != 9 {
+			t.Errorf("Partition() = %d, want hash key 9 verbatim", got)
+		}
+	})
+}
@@ -7,11 +7,67 @@ import (
 	"github.com/zilliztech/milvus-distributed/internal/proto/internalpb"
 )
 
+// RepackFunc repacks a slice of TsMsg into per-hash-key message packs that are
+// ready to be published on the underlying message queue.
+type RepackFunc func(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, error)
+
+// RepackFuncBuilder returns a RepackFunc that places rows with p instead of trusting
+// the caller-supplied hashKeys verbatim, so InsertRepackFunc, DeleteRepackFunc and
+// DefaultRepackFunc and any caller-defined partitioning scheme all compose from the
+// same partitioning core. observers, if given, are reported the ratio of rows that
+// landed on a bucket other than their original hash key, so callers can judge how
+// disruptive switching to p would be before rolling it out.
+func RepackFuncBuilder(p Partitioner, observers ...RebalanceObserver) RepackFunc {
+	return func(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, error) {
+		if len(tsMsgs) == 0 {
+			return map[int32]*MsgPack{}, nil
+		}
+
+		var (
+			result       map[int32]*MsgPack
+			moved, total int
+			err          error
+		)
+
+		switch tsMsgs[0].Type() {
+		case commonpb.MsgType_Insert:
+			result, moved, total, err = repackInsert(tsMsgs, hashKeys, p)
+		case commonpb.MsgType_Delete:
+			result, moved, total, err = repackDelete(tsMsgs, hashKeys, p)
+		default:
+			result, moved, total, err = repackDefault(tsMsgs, hashKeys, p)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(observers) > 0 {
+			ratio := 0.0
+			if total > 0 {
+				ratio = float64(moved) / float64(total)
+			}
+			for _, observe := range observers {
+				observe(moved, total, ratio)
+			}
+		}
+
+		return result, nil
+	}
+}
+
+// InsertRepackFunc repacks InsertMsgs into one single-row InsertMsg per row, keyed
+// by the caller-supplied hashKeys.
 func InsertRepackFunc(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, error) {
+	result, _, _, err := repackInsert(tsMsgs, hashKeys, defaultPartitioner)
+	return result, err
+}
+
+func repackInsert(tsMsgs []TsMsg, hashKeys [][]int32, p Partitioner) (map[int32]*MsgPack, int, int, error) {
 	result := make(map[int32]*MsgPack)
+	moved, total := 0, 0
 	for i, request := range tsMsgs {
 		if request.Type() != commonpb.MsgType_Insert {
-			return nil, errors.New("msg's must be Insert")
+			return nil, 0, 0, errors.New("msg's must be Insert")
 		}
 		insertRequest := request.(*InsertMsg)
 		keys := hashKeys[i]
@@ -22,13 +78,23 @@ func InsertRepackFunc(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, e
 		keysLen := len(keys)
 
 		if keysLen != timestampLen || keysLen != rowIDLen || keysLen != rowDataLen {
-			return nil, errors.New("the length of hashValue, timestamps, rowIDs, RowData are not equal")
+			return nil, 0, 0, errors.New("the length of hashValue, timestamps, rowIDs, RowData are not equal")
 		}
-		for index, key := range keys {
-			_, ok := result[key]
+		for index, rawKey := range keys {
+			bucket := p.Partition(PartitionKey{
+				HashKey:     rawKey,
+				SegmentID:   insertRequest.SegmentID,
+				PartitionID: insertRequest.PartitionID,
+			})
+			total++
+			if bucket != rawKey {
+				moved++
+			}
+
+			_, ok := result[bucket]
 			if !ok {
 				msgPack := MsgPack{}
-				result[key] = &msgPack
+				result[bucket] = &msgPack
 			}
 
 			sliceRequest := internalpb.InsertRequest{
@@ -56,17 +122,25 @@ func InsertRepackFunc(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, e
 				},
 				InsertRequest: sliceRequest,
 			}
-			result[key].Msgs = append(result[key].Msgs, insertMsg)
+			result[bucket].Msgs = append(result[bucket].Msgs, insertMsg)
 		}
 	}
-	return result, nil
+	return result, moved, total, nil
 }
 
+// DeleteRepackFunc repacks DeleteMsgs into one single-row DeleteMsg per row, keyed
+// by the caller-supplied hashKeys.
 func DeleteRepackFunc(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, error) {
+	result, _, _, err := repackDelete(tsMsgs, hashKeys, defaultPartitioner)
+	return result, err
+}
+
+func repackDelete(tsMsgs []TsMsg, hashKeys [][]int32, p Partitioner) (map[int32]*MsgPack, int, int, error) {
 	result := make(map[int32]*MsgPack)
+	moved, total := 0, 0
 	for i, request := range tsMsgs {
 		if request.Type() != commonpb.MsgType_Delete {
-			return nil, errors.New("msg's must be Delete")
+			return nil, 0, 0, errors.New("msg's must be Delete")
 		}
 		deleteRequest := request.(*DeleteMsg)
 		keys := hashKeys[i]
@@ -76,14 +150,23 @@ func DeleteRepackFunc(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, e
 		keysLen := len(keys)
 
 		if keysLen != timestampLen || keysLen != primaryKeysLen {
-			return nil, errors.New("the length of hashValue, timestamps, primaryKeys are not equal")
+			return nil, 0, 0, errors.New("the length of hashValue, timestamps, primaryKeys are not equal")
 		}
 
-		for index, key := range keys {
-			_, ok := result[key]
+		for index, rawKey := range keys {
+			bucket := p.Partition(PartitionKey{
+				HashKey:    rawKey,
+				PrimaryKey: deleteRequest.PrimaryKeys[index],
+			})
+			total++
+			if bucket != rawKey {
+				moved++
+			}
+
+			_, ok := result[bucket]
 			if !ok {
 				msgPack := MsgPack{}
-				result[key] = &msgPack
+				result[bucket] = &msgPack
 			}
 
 			sliceRequest := internalpb.DeleteRequest{
@@ -105,26 +188,41 @@ func DeleteRepackFunc(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, e
 				},
 				DeleteRequest: sliceRequest,
 			}
-			result[key].Msgs = append(result[key].Msgs, deleteMsg)
+			result[bucket].Msgs = append(result[bucket].Msgs, deleteMsg)
 		}
 	}
-	return result, nil
+	return result, moved, total, nil
 }
 
+// DefaultRepackFunc repacks any TsMsg that carries exactly one hash key per
+// message, used for message types that are never split per-row (e.g. control
+// messages).
 func DefaultRepackFunc(tsMsgs []TsMsg, hashKeys [][]int32) (map[int32]*MsgPack, error) {
+	result, _, _, err := repackDefault(tsMsgs, hashKeys, defaultPartitioner)
+	return result, err
+}
+
+func repackDefault(tsMsgs []TsMsg, hashKeys [][]int32, p Partitioner) (map[int32]*MsgPack, int, int, error) {
 	result := make(map[int32]*MsgPack)
+	moved, total := 0, 0
 	for i, request := range tsMsgs {
 		keys := hashKeys[i]
 		if len(keys) != 1 {
-			return nil, errors.New("len(msg.hashValue) must equal 1")
+			return nil, 0, 0, errors.New("len(msg.hashValue) must equal 1")
 		}
-		key := keys[0]
-		_, ok := result[key]
+		rawKey := keys[0]
+		bucket := p.Partition(PartitionKey{HashKey: rawKey})
+		total++
+		if bucket != rawKey {
+			moved++
+		}
+
+		_, ok := result[bucket]
 		if !ok {
 			msgPack := MsgPack{}
-			result[key] = &msgPack
+			result[bucket] = &msgPack
 		}
-		result[key].Msgs = append(result[key].Msgs, request)
+		result[bucket].Msgs = append(result[bucket].Msgs, request)
 	}
-	return result, nil
-}
\ No newline at end of file
+	return result, moved, total, nil
+}
@@ -3,15 +3,68 @@ package reader
 import (
 	"errors"
 	"fmt"
-	"github.com/zilliztech/milvus-distributed/internal/proto/commonpb"
 	"log"
 	"strconv"
 	"sync"
+
+	"github.com/zilliztech/milvus-distributed/internal/proto/commonpb"
 )
 
+// defaultInsertPoolSize bounds insertNode's worker pool when ParamTable does not
+// configure one.
+const defaultInsertPoolSize = 16
+
+// defaultDeadLetterSize bounds how many failed inserts insertNode buffers for
+// inspection; once it is full, further failed inserts are logged and dropped.
+const defaultDeadLetterSize = 64
+
+// maxInsertRetries bounds how many times insertNode retries a segment insert that
+// fails with a transient error before giving up on it.
+const maxInsertRetries = 3
+
+// InsertError describes a segment insert that failed inside insertNode.Operate,
+// carrying enough context (segment, offset, underlying cause) to retry or inspect
+// the offending batch instead of only logging it.
+type InsertError struct {
+	SegmentID int64
+	Offset    int64
+	Cause     error
+}
+
+func (e *InsertError) Error() string {
+	return fmt.Sprintf("insert failed for segment %d at offset %d: %v", e.SegmentID, e.Offset, e.Cause)
+}
+
+func (e *InsertError) Unwrap() error {
+	return e.Cause
+}
+
+// segmentNotFoundError indicates insertNode has no segment registered under the
+// given ID. It is not worth retrying: the segment either never existed in
+// segmentsMap or was deleted, and neither heals itself on a later attempt.
+type segmentNotFoundError struct {
+	segmentID int64
+}
+
+func (e *segmentNotFoundError) Error() string {
+	return "cannot found segment with id = " + strconv.FormatInt(e.segmentID, 10)
+}
+
+type insertResult struct {
+	segmentID int64
+	err       *InsertError
+}
+
 type insertNode struct {
 	BaseNode
 	segmentsMap *map[int64]*Segment
+
+	// poolSize bounds how many segments insertNode inserts into concurrently.
+	poolSize int
+	// deadLetter receives errors for inserts that failed for good (a non-transient
+	// error, or a transient one that outlasted maxInsertRetries retries), so they
+	// can be inspected or replayed instead of dropped.
+	deadLetter chan *InsertError
 }
 
 type InsertData struct {
@@ -25,6 +78,13 @@ func (iNode *insertNode) Name() string {
 	return "iNode"
 }
 
+// DeadLetters returns the channel insertNode publishes to when a segment insert
+// fails for good (a non-transient error, or retries exhausted), so callers can
+// inspect or replay it instead of it being silently dropped.
+func (iNode *insertNode) DeadLetters() <-chan *InsertError {
+	return iNode.deadLetter
+}
+
 func (iNode *insertNode) Operate(in []*Msg) []*Msg {
 	// fmt.Println("Do insertNode operation")
 
@@ -63,8 +123,8 @@ func (iNode *insertNode) Operate(in []*Msg) []*Msg {
 	for segmentID := range insertData.insertRecords {
 		var targetSegment, err = iNode.getSegmentBySegmentID(segmentID)
 		if err != nil {
-			log.Println("preInsert failed")
-			// TODO: add error handling
+			log.Println("preInsert failed:", err)
+			continue
 		}
 
 		var numOfRecords = len(insertData.insertRecords[segmentID])
@@ -74,13 +134,13 @@ func (iNode *insertNode) Operate(in []*Msg) []*Msg {
 		}
 	}
 
-	// 3. do insert
-	wg := sync.WaitGroup{}
-	for segmentID := range insertData.insertRecords {
-		wg.Add(1)
-		go iNode.insert(&insertData, segmentID, &wg)
+	// 3. do insert through a bounded worker pool. Any insert that still fails after
+	// retrying is published on iNode.DeadLetters() instead of silently dropped;
+	// the flow graph's Msg type carries no room for an error, so that channel -
+	// not the returned Msg - is how a caller observes an insert failure.
+	if firstFatal := iNode.insertAll(&insertData); firstFatal != nil {
+		log.Println("insertNode.Operate hit a fatal error:", firstFatal)
 	}
-	wg.Wait()
 
 	var res Msg = &serviceTimeMsg{
 		timeRange: iMsg.timeRange,
@@ -88,38 +148,107 @@ func (iNode *insertNode) Operate(in []*Msg) []*Msg {
 	return []*Msg{&res}
 }
 
+// insertAll inserts every segment in insertData through a worker pool bounded by
+// iNode.poolSize, routing any insert that still fails after retrying to
+// iNode.deadLetter. It returns the first fatal InsertError encountered, if any.
+func (iNode *insertNode) insertAll(insertData *InsertData) *InsertError {
+	segmentIDs := make([]int64, 0, len(insertData.insertRecords))
+	for segmentID := range insertData.insertRecords {
+		segmentIDs = append(segmentIDs, segmentID)
+	}
+
+	results := make(chan insertResult, len(segmentIDs))
+	sem := make(chan struct{}, iNode.poolSize)
+	wg := sync.WaitGroup{}
+
+	for _, segmentID := range segmentIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(segmentID int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results <- insertResult{
+				segmentID: segmentID,
+				err:       iNode.insertWithRetry(insertData, segmentID),
+			}
+		}(segmentID)
+	}
+	wg.Wait()
+	close(results)
+
+	var firstFatal *InsertError
+	for result := range results {
+		if result.err == nil {
+			continue
+		}
+		if firstFatal == nil {
+			firstFatal = result.err
+		}
+		select {
+		case iNode.deadLetter <- result.err:
+		default:
+			log.Println("insertNode dead-letter channel full, dropping:", result.err)
+		}
+	}
+	return firstFatal
+}
+
+// insertWithRetry retries a transient segmentInsert failure up to
+// maxInsertRetries times, returning a typed InsertError once it gives up.
+func (iNode *insertNode) insertWithRetry(insertData *InsertData, segmentID int64) *InsertError {
+	var lastErr error
+	for attempt := 0; attempt <= maxInsertRetries; attempt++ {
+		lastErr = iNode.insert(insertData, segmentID)
+		if lastErr == nil {
+			return nil
+		}
+		if !isTransientInsertError(lastErr) {
+			break
+		}
+	}
+
+	return &InsertError{
+		SegmentID: segmentID,
+		Offset:    insertData.insertOffset[segmentID],
+		Cause:     lastErr,
+	}
+}
+
+// isTransientInsertError reports whether err is worth retrying. A missing segment
+// never resolves itself within the retry window, so only that case is treated as
+// non-transient.
+func isTransientInsertError(err error) bool {
+	var notFound *segmentNotFoundError
+	return !errors.As(err, &notFound)
+}
+
 func (iNode *insertNode) getSegmentBySegmentID(segmentID int64) (*Segment, error) {
 	targetSegment, ok := (*iNode.segmentsMap)[segmentID]
 
 	if !ok {
-		return nil, errors.New("cannot found segment with id = " + strconv.FormatInt(segmentID, 10))
+		return nil, &segmentNotFoundError{segmentID: segmentID}
 	}
 
 	return targetSegment, nil
 }
 
-func (iNode *insertNode) insert(insertData *InsertData, segmentID int64, wg *sync.WaitGroup) {
-	var targetSegment, err = iNode.getSegmentBySegmentID(segmentID)
+func (iNode *insertNode) insert(insertData *InsertData, segmentID int64) error {
+	targetSegment, err := iNode.getSegmentBySegmentID(segmentID)
 	if err != nil {
-		log.Println("cannot find segment:", segmentID)
-		// TODO: add error handling
-		return
+		return err
 	}
 
 	ids := insertData.insertIDs[segmentID]
 	timestamps := insertData.insertTimestamps[segmentID]
 	records := insertData.insertRecords[segmentID]
-	offsets := insertData.insertOffset[segmentID]
+	offset := insertData.insertOffset[segmentID]
 
-	err = targetSegment.segmentInsert(offsets, &ids, &timestamps, &records)
-	if err != nil {
-		log.Println("insert failed")
-		// TODO: add error handling
-		return
+	if err = targetSegment.segmentInsert(offset, &ids, &timestamps, &records); err != nil {
+		return err
 	}
 
-	fmt.Println("Do insert done, len = ", len(insertData.insertIDs[segmentID]))
-	wg.Done()
+	log.Println("Do insert done, len = ", len(ids))
+	return nil
 }
 
 func newInsertNode(segmentsMap *map[int64]*Segment) *insertNode {
@@ -127,8 +256,15 @@ func newInsertNode(segmentsMap *map[int64]*Segment) *insertNode {
 	baseNode.SetMaxQueueLength(maxQueueLength)
 	baseNode.SetMaxParallelism(maxParallelism)
 
+	poolSize := Params.InsertPoolSize
+	if poolSize <= 0 {
+		poolSize = defaultInsertPoolSize
+	}
+
 	return &insertNode{
 		BaseNode:    baseNode,
 		segmentsMap: segmentsMap,
+		poolSize:    poolSize,
+		deadLetter:  make(chan *InsertError, defaultDeadLetterSize),
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,112 @@
+package reader
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/zilliztech/milvus-distributed/internal/proto/commonpb"
+)
+
+// newTestInsertNode returns an insertNode whose segmentsMap has no registered
+// segments, so every insert deterministically fails with segmentNotFoundError
+// without requiring a working Segment.
+func newTestInsertNode(poolSize int, deadLetterSize int) *insertNode {
+	segmentsMap := make(map[int64]*Segment)
+	return &insertNode{
+		segmentsMap: &segmentsMap,
+		poolSize:    poolSize,
+		deadLetter:  make(chan *InsertError, deadLetterSize),
+	}
+}
+
+func insertDataForSegments(segmentIDs ...int64) *InsertData {
+	data := &InsertData{
+		insertIDs:        make(map[int64][]int64),
+		insertTimestamps: make(map[int64][]uint64),
+		insertRecords:    make(map[int64][]*commonpb.Blob),
+		insertOffset:     make(map[int64]int64),
+	}
+	for _, segmentID := range segmentIDs {
+		data.insertRecords[segmentID] = []*commonpb.Blob{{Value: []byte{byte(segmentID)}}}
+	}
+	return data
+}
+
+func TestInsertAllAggregatesConcurrentErrors(t *testing.T) {
+	const numSegments = 20
+	segmentIDs := make([]int64, numSegments)
+	for i := range segmentIDs {
+		segmentIDs[i] = int64(i + 1)
+	}
+
+	iNode := newTestInsertNode(4, numSegments)
+	firstFatal := iNode.insertAll(insertDataForSegments(segmentIDs...))
+
+	if firstFatal == nil {
+		t.Fatal("insertAll() returned nil, want a fatal error since every segment is unregistered")
+	}
+
+	got := 0
+	for len(iNode.deadLetter) > 0 {
+		<-iNode.deadLetter
+		got++
+	}
+	if got != numSegments {
+		t.Errorf("deadLetter received %d errors, want %d (one per failed segment)", got, numSegments)
+	}
+}
+
+func TestInsertAllDropsOnDeadLetterOverflow(t *testing.T) {
+	const numSegments = 10
+	const deadLetterSize = 3
+	segmentIDs := make([]int64, numSegments)
+	for i := range segmentIDs {
+		segmentIDs[i] = int64(i + 1)
+	}
+
+	iNode := newTestInsertNode(4, deadLetterSize)
+
+	firstFatal := iNode.insertAll(insertDataForSegments(segmentIDs...))
+	if firstFatal == nil {
+		t.Fatal("insertAll() returned nil, want a fatal error since every segment is unregistered")
+	}
+
+	if got := len(iNode.deadLetter); got != deadLetterSize {
+		t.Errorf("deadLetter holds %d errors, want exactly the channel capacity %d (excess dropped, not blocked on)", got, deadLetterSize)
+	}
+}
+
+func TestInsertAllNoSegmentsIsNoop(t *testing.T) {
+	iNode := newTestInsertNode(4, defaultDeadLetterSize)
+	if firstFatal := iNode.insertAll(insertDataForSegments()); firstFatal != nil {
+		t.Errorf("insertAll() with no segments = %v, want nil", firstFatal)
+	}
+}
+
+func TestSegmentNotFoundErrorIsNotTransient(t *testing.T) {
+	err := &segmentNotFoundError{segmentID: 7}
+	if isTransientInsertError(err) {
+		t.Error("isTransientInsertError(segmentNotFoundError) = true, want false")
+	}
+	if want := "cannot found segment with id = " + strconv.FormatInt(7, 10); err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestInsertWithRetryGivesUpOnNonTransientError(t *testing.T) {
+	iNode := newTestInsertNode(1, defaultDeadLetterSize)
+	data := insertDataForSegments(1)
+
+	insertErr := iNode.insertWithRetry(data, 1)
+	if insertErr == nil {
+		t.Fatal("insertWithRetry() = nil, want an error for an unregistered segment")
+	}
+	if insertErr.SegmentID != 1 {
+		t.Errorf("InsertError.SegmentID = %d, want 1", insertErr.SegmentID)
+	}
+	var notFound *segmentNotFoundError
+	if !errors.As(insertErr.Cause, &notFound) {
+		t.Errorf("InsertError.Cause = %v, want a *segmentNotFoundError", insertErr.Cause)
+	}
+}
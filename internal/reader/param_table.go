@@ -0,0 +1,38 @@
+package reader
+
+import (
+	"strconv"
+
+	"github.com/zilliztech/milvus-distributed/internal/util/paramtable"
+)
+
+// ParamTable holds reader package configuration loaded from the shared config
+// source, the same pattern grpcproxynode.ParamTable follows.
+type ParamTable struct {
+	paramtable.BaseTable
+
+	// InsertPoolSize bounds how many segments insertNode inserts into
+	// concurrently; see newInsertNode. Falls back to defaultInsertPoolSize when
+	// unset or invalid, since it is a tunable knob rather than a required value.
+	InsertPoolSize int
+}
+
+var Params ParamTable
+
+func (pt *ParamTable) Init() {
+	pt.BaseTable.Init()
+
+	pt.initInsertPoolSize()
+}
+
+func (pt *ParamTable) initInsertPoolSize() {
+	pt.InsertPoolSize = defaultInsertPoolSize
+
+	size, err := pt.Load("reader.insertPoolSize")
+	if err != nil {
+		return
+	}
+	if parsed, err := strconv.Atoi(size); err == nil && parsed > 0 {
+		pt.InsertPoolSize = parsed
+	}
+}